@@ -0,0 +1,432 @@
+// Command go-deadcode-lsp is an LSP server that surfaces the removals the
+// CLI would otherwise perform on disk as editor quickfixes. It never
+// writes a file itself: textDocument/codeAction returns a WorkspaceEdit
+// and it's up to the client (and the user) to apply it.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/sourcegraph/jsonrpc2"
+
+	"github.com/ChenJesse/go-deadcode/internal/config"
+	"github.com/ChenJesse/go-deadcode/internal/deadcode"
+	"github.com/ChenJesse/go-deadcode/internal/rewrite"
+)
+
+// configPath is the keep-list/roots config the server consults, resolved
+// relative to its working directory — same default the CLI uses, and
+// normally the workspace root the client launches the server from.
+const configPath = ".deadcode.yaml"
+
+func main() {
+	log.SetFlags(0)
+	stream := jsonrpc2.NewBufferedStream(stdrwc{}, jsonrpc2.VSCodeObjectCodec{})
+	<-jsonrpc2.NewConn(context.Background(), stream, newHandler()).DisconnectNotify()
+}
+
+// stdrwc adapts stdin/stdout to an io.ReadWriteCloser, the transport gopls
+// and friends use when launched as a per-editor subprocess.
+type stdrwc struct{}
+
+func (stdrwc) Read(p []byte) (int, error)  { return os.Stdin.Read(p) }
+func (stdrwc) Write(p []byte) (int, error) { return os.Stdout.Write(p) }
+func (stdrwc) Close() error {
+	if err := os.Stdin.Close(); err != nil {
+		return err
+	}
+	return os.Stdout.Close()
+}
+
+// Position, Range, TextEdit, and WorkspaceEdit mirror the subset of the LSP
+// spec this server needs.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+type WorkspaceEdit struct {
+	Changes map[string][]TextEdit `json:"changes"`
+}
+
+type TextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type CodeActionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+}
+
+type CodeAction struct {
+	Title string         `json:"title"`
+	Kind  string         `json:"kind"`
+	Edit  *WorkspaceEdit `json:"edit,omitempty"`
+}
+
+type DidOpenTextDocumentParams struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+}
+
+type DidSaveTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+const (
+	codeActionKindQuickFix     = "quickfix"
+	codeActionKindRemoveUnused = "source.removeUnused"
+)
+
+// fileRemovals caches, per file, the edits a round of analysis found. It's
+// rebuilt on every didOpen/didSave and read back on codeAction so the
+// (possibly slow) whole-package analysis doesn't run on every keystroke.
+type handler struct {
+	mu       sync.Mutex
+	removals map[string][]removalEdit // uriToPath(uri) -> edits
+
+	cfg  *config.Config
+	keep []*regexp.Regexp
+}
+
+// removalEdit is the single edit that fixes every removal Rewrite made
+// within one top-level decl — grouped, because a grouped var/const/type
+// block can lose more than one name to a single edit that replaces the
+// whole decl at once.
+type removalEdit struct {
+	removals []rewrite.Removal
+	rng      Range
+	newText  string
+}
+
+func newHandler() *handler {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	keep, err := cfg.CompiledKeep()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return &handler{removals: map[string][]removalEdit{}, cfg: cfg, keep: keep}
+}
+
+func (h *handler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	switch req.Method {
+	case "initialize":
+		h.reply(ctx, conn, req, map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"codeActionProvider": map[string]interface{}{
+					"codeActionKinds": []string{codeActionKindQuickFix, codeActionKindRemoveUnused},
+				},
+			},
+		})
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			log.Printf("didOpen: %v", err)
+			return
+		}
+		h.analyze(params.TextDocument.URI)
+	case "textDocument/didSave":
+		var params DidSaveTextDocumentParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			log.Printf("didSave: %v", err)
+			return
+		}
+		h.analyze(params.TextDocument.URI)
+	case "textDocument/codeAction":
+		var params CodeActionParams
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			log.Printf("codeAction: %v", err)
+			return
+		}
+		h.reply(ctx, conn, req, h.codeActions(params))
+	}
+}
+
+func (h *handler) reply(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, result interface{}) {
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		log.Printf("reply to %s: %v", req.Method, err)
+	}
+}
+
+// analyze loads the package enclosing uri's file, runs the VTA
+// whole-program backend over it, and re-parses the file to map each
+// unused subject back to the precise ast.Decl (or spec name) range
+// handleUnused would otherwise delete, via the same rewrite.Rewrite logic
+// the CLI uses.
+func (h *handler) analyze(uri string) {
+	path := uriToPath(uri)
+
+	results, err := deadcode.Analyze([]string{fmt.Sprintf("file=%s", path)}, h.cfg.Roots)
+	if err != nil {
+		log.Printf("analyze %s: %v", path, err)
+		return
+	}
+
+	metadata := &rewrite.FileMetadata{FileName: path, Subjects: map[rewrite.Subject]struct{}{}}
+	kindToSubjectKind := map[deadcode.SubjectKind]rewrite.SubjectKind{
+		deadcode.Func:   rewrite.Func,
+		deadcode.Type:   rewrite.Type,
+		deadcode.Method: rewrite.Method,
+	}
+	for _, res := range results {
+		if res.File != path {
+			continue
+		}
+		for _, subject := range res.Subjects {
+			if config.Matches(h.keep, subject.Name) {
+				continue
+			}
+			metadata.Subjects[rewrite.Subject{Name: subject.Name, Kind: kindToSubjectKind[subject.Kind]}] = struct{}{}
+		}
+	}
+
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	if err != nil {
+		log.Printf("parse %s: %v", path, err)
+		return
+	}
+	cmap := ast.NewCommentMap(fset, node, node.Comments)
+
+	// Snapshot each top-level decl's original start/end, and the file's
+	// original comment list, before Rewrite mutates node.Decls and
+	// node.Comments — so declEdit below can diff "was this decl dropped
+	// entirely, or did it survive with some of its specs pruned" against
+	// the pre-rewrite list, reformat a survived decl with the floating
+	// comments it originally had, and so the replacement range always
+	// covers a whole decl rather than a bare name — a removed *ast.Ident
+	// can't by itself express "also delete its value and the separating
+	// comma". The span snapshot matters on its own: a no-parens GenDecl's
+	// End() is computed from its last Spec, so calling it after Rewrite
+	// has pruned every Spec out from under a dropped decl panics.
+	origDecls := make([]ast.Decl, len(node.Decls))
+	origSpans := make(map[ast.Decl]span, len(node.Decls))
+	for i, d := range node.Decls {
+		origDecls[i] = d
+		origSpans[d] = span{d.Pos(), d.End()}
+	}
+	origComments := append([]*ast.CommentGroup(nil), node.Comments...)
+
+	removals := rewrite.Rewrite(node, cmap, metadata)
+
+	survived := make(map[ast.Decl]bool, len(node.Decls))
+	for _, d := range node.Decls {
+		survived[d] = true
+	}
+
+	// Group removals by their enclosing original decl: a grouped
+	// var/const/type block can lose more than one name in a single
+	// Rewrite pass, and each loses its own Removal, but they all collapse
+	// to one edit that replaces the whole decl, so they must share one
+	// removalEdit rather than each claiming the same range independently.
+	var order []ast.Decl
+	byDecl := map[ast.Decl][]rewrite.Removal{}
+	for _, r := range removals {
+		decl := enclosingDecl(origDecls, origSpans, r.Node.Pos())
+		if decl == nil {
+			// r.Node should always lie within one of origDecls, since
+			// decls never nest; skip rather than guess at a range if
+			// that invariant ever breaks.
+			continue
+		}
+		if _, ok := byDecl[decl]; !ok {
+			order = append(order, decl)
+		}
+		byDecl[decl] = append(byDecl[decl], r)
+	}
+
+	edits := make([]removalEdit, 0, len(order))
+	for _, decl := range order {
+		rng, newText := declEdit(fset, decl, origSpans[decl], origComments, survived[decl])
+		edits = append(edits, removalEdit{removals: byDecl[decl], rng: rng, newText: newText})
+	}
+
+	h.mu.Lock()
+	h.removals[path] = edits
+	h.mu.Unlock()
+}
+
+// span is a node's original [start, end) position range, captured before
+// Rewrite mutates it.
+type span struct {
+	start, end token.Pos
+}
+
+// enclosingDecl returns whichever of decls (using their pre-rewrite spans)
+// contains pos, or nil if none does. Every Removal's node — whole decl or
+// a single pruned name — lies inside exactly one original top-level decl,
+// since decls never nest.
+func enclosingDecl(decls []ast.Decl, spans map[ast.Decl]span, pos token.Pos) ast.Decl {
+	for _, d := range decls {
+		s := spans[d]
+		if pos >= s.start && pos < s.end {
+			return d
+		}
+	}
+	return nil
+}
+
+// declEdit computes the WorkspaceEdit for one top-level decl that lost at
+// least one of its members. If decl didn't survive Rewrite at all, it's a
+// straight deletion. If it did survive — a grouped var/const/type decl
+// that kept some specs, or had names pruned out of a ValueSpec — the only
+// edit that's guaranteed syntactically valid is replacing the whole decl
+// with decl's post-Rewrite form reformatted from scratch, since a partial
+// prune changes two independent lists (Names and Values) that don't share
+// a single contiguous range to delete.
+func declEdit(fset *token.FileSet, decl ast.Decl, origSpan span, comments []*ast.CommentGroup, survived bool) (Range, string) {
+	rng := declRange(fset, decl, origSpan)
+	if !survived {
+		return rng, ""
+	}
+	newText, err := formatDecl(fset, decl, origSpan, comments)
+	if err != nil {
+		// Can't reformat the pruned decl; fall back to deleting it
+		// outright rather than risking invalid Go.
+		return rng, ""
+	}
+	return rng, newText
+}
+
+// formatDecl renders decl back to source, keeping any floating comment
+// that fell inside decl's original span (such as one between two specs in
+// a var/const block) even though it's attached to no surviving node.
+// format.Node on the bare decl would drop it: only an *ast.File carries a
+// Comments list, so decl is wrapped in a throwaway one built just for this
+// call, and the synthetic "package p" header format.Node emits for it is
+// trimmed back off.
+func formatDecl(fset *token.FileSet, decl ast.Decl, origSpan span, comments []*ast.CommentGroup) (string, error) {
+	var relevant []*ast.CommentGroup
+	for _, cg := range comments {
+		if cg.Pos() >= origSpan.start && cg.End() <= origSpan.end {
+			relevant = append(relevant, cg)
+		}
+	}
+
+	tmp := &ast.File{Name: ast.NewIdent("p"), Decls: []ast.Decl{decl}, Comments: relevant}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, tmp); err != nil {
+		return "", err
+	}
+	const header = "package p\n\n"
+	return strings.TrimSuffix(strings.TrimPrefix(buf.String(), header), "\n"), nil
+}
+
+// declRange returns the deletion range for a removed node, extended to
+// cover its doc comment (if any) so the quickfix doesn't leave an orphaned
+// comment behind — the same doc-comment handling rewrite.Rewrite's
+// cmap.Filter(node).Comments() call performs for the in-place rewriter.
+// origSpan.end stands in for node.End(), which isn't safe to call once
+// Rewrite has pruned node's children.
+func declRange(fset *token.FileSet, node ast.Node, origSpan span) Range {
+	start := origSpan.start
+	switch n := node.(type) {
+	case *ast.FuncDecl:
+		if n.Doc != nil {
+			start = n.Doc.Pos()
+		}
+	case *ast.GenDecl:
+		if n.Doc != nil {
+			start = n.Doc.Pos()
+		}
+	case *ast.TypeSpec:
+		if n.Doc != nil {
+			start = n.Doc.Pos()
+		}
+	case *ast.ValueSpec:
+		if n.Doc != nil {
+			start = n.Doc.Pos()
+		}
+	}
+	return toRange(fset, start, origSpan.end)
+}
+
+func toRange(fset *token.FileSet, start, end token.Pos) Range {
+	s, e := fset.Position(start), fset.Position(end)
+	return Range{
+		Start: Position{Line: s.Line - 1, Character: s.Column - 1},
+		End:   Position{Line: e.Line - 1, Character: e.Column - 1},
+	}
+}
+
+// codeActions turns the cached removals for params.TextDocument that
+// overlap params.Range into quickfix CodeActions.
+func (h *handler) codeActions(params CodeActionParams) []CodeAction {
+	path := uriToPath(params.TextDocument.URI)
+
+	h.mu.Lock()
+	edits := h.removals[path]
+	h.mu.Unlock()
+
+	var actions []CodeAction
+	for _, e := range edits {
+		if !rangesOverlap(e.rng, params.Range) {
+			continue
+		}
+		actions = append(actions, CodeAction{
+			Title: removalTitle(e.removals),
+			Kind:  codeActionKindRemoveUnused,
+			Edit: &WorkspaceEdit{
+				Changes: map[string][]TextEdit{
+					params.TextDocument.URI: {{Range: e.rng, NewText: e.newText}},
+				},
+			},
+		})
+	}
+	return actions
+}
+
+// removalTitle renders the quickfix title for a group of removals that
+// share one edit: a single name keeps the original wording, and more than
+// one (e.g. two unused consts pruned from the same block) are listed
+// together so the title doesn't undersell what applying the edit does.
+func removalTitle(removals []rewrite.Removal) string {
+	if len(removals) == 1 {
+		return fmt.Sprintf("Remove unused %s %q", removals[0].Kind, removals[0].Name)
+	}
+	names := make([]string, len(removals))
+	for i, r := range removals {
+		names[i] = fmt.Sprintf("%q", r.Name)
+	}
+	return fmt.Sprintf("Remove unused %ss %s", removals[0].Kind, strings.Join(names, ", "))
+}
+
+func rangesOverlap(a, b Range) bool {
+	return !(a.End.Line < b.Start.Line || b.End.Line < a.Start.Line)
+}
+
+// uriToPath strips the file:// scheme LSP clients send text document URIs
+// with; this server only ever deals with local files.
+func uriToPath(uri string) string {
+	const prefix = "file://"
+	if len(uri) >= len(prefix) && uri[:len(prefix)] == prefix {
+		return uri[len(prefix):]
+	}
+	return uri
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/ChenJesse/go-deadcode/internal/rewrite"
+)
+
+// buildEdits runs the same decl-snapshot-then-Rewrite sequence analyze
+// uses, so tests can check the resulting edits without going through
+// deadcode.Analyze's whole-package load.
+func buildEdits(t *testing.T, src string, metadata *rewrite.FileMetadata) (*token.FileSet, []removalEdit) {
+	t.Helper()
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, "test.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	cmap := ast.NewCommentMap(fset, node, node.Comments)
+
+	origDecls := make([]ast.Decl, len(node.Decls))
+	origSpans := make(map[ast.Decl]span, len(node.Decls))
+	for i, d := range node.Decls {
+		origDecls[i] = d
+		origSpans[d] = span{d.Pos(), d.End()}
+	}
+	origComments := append([]*ast.CommentGroup(nil), node.Comments...)
+
+	removals := rewrite.Rewrite(node, cmap, metadata)
+
+	survived := make(map[ast.Decl]bool, len(node.Decls))
+	for _, d := range node.Decls {
+		survived[d] = true
+	}
+
+	var order []ast.Decl
+	byDecl := map[ast.Decl][]rewrite.Removal{}
+	for _, r := range removals {
+		decl := enclosingDecl(origDecls, origSpans, r.Node.Pos())
+		if decl == nil {
+			t.Fatalf("enclosingDecl: no decl contains removal %q", r.Name)
+		}
+		if _, ok := byDecl[decl]; !ok {
+			order = append(order, decl)
+		}
+		byDecl[decl] = append(byDecl[decl], r)
+	}
+
+	edits := make([]removalEdit, 0, len(order))
+	for _, decl := range order {
+		rng, newText := declEdit(fset, decl, origSpans[decl], origComments, survived[decl])
+		edits = append(edits, removalEdit{removals: byDecl[decl], rng: rng, newText: newText})
+	}
+	return fset, edits
+}
+
+// TestDeclEdit_PartialValueSpecPruneReplacesWholeDecl is a regression test:
+// pruning one name out of a multi-name var spec must produce an edit that
+// replaces the whole surviving decl, not just the removed identifier —
+// deleting only the identifier would leave the Names and Values lists out
+// of sync and fail to parse.
+func TestDeclEdit_PartialValueSpecPruneReplacesWholeDecl(t *testing.T) {
+	const src = `package p
+
+var unused, used = 1, 2
+`
+	metadata := &rewrite.FileMetadata{
+		Subjects: map[rewrite.Subject]struct{}{
+			{Name: "unused", Kind: rewrite.Var}: {},
+		},
+	}
+
+	_, edits := buildEdits(t, src, metadata)
+	if len(edits) != 1 {
+		t.Fatalf("got %d edits, want 1", len(edits))
+	}
+
+	const want = "var used = 2"
+	if edits[0].newText != want {
+		t.Errorf("newText = %q, want %q", edits[0].newText, want)
+	}
+
+	// The replacement text must itself parse as a valid declaration —
+	// applying the edit at edits[0].rng must not leave invalid Go behind.
+	if _, err := parser.ParseFile(token.NewFileSet(), "", "package p\n"+edits[0].newText+"\n", 0); err != nil {
+		t.Errorf("replacement text does not parse: %v", err)
+	}
+}
+
+// TestDeclEdit_WholeGenDeclDropStillDeletes is a regression test: when
+// every name in a decl is pruned, the decl doesn't survive Rewrite at all,
+// and the edit must fall back to a plain deletion rather than trying to
+// reformat a decl that's no longer there.
+func TestDeclEdit_WholeGenDeclDropStillDeletes(t *testing.T) {
+	const src = `package p
+
+var unused = 1
+`
+	metadata := &rewrite.FileMetadata{
+		Subjects: map[rewrite.Subject]struct{}{
+			{Name: "unused", Kind: rewrite.Var}: {},
+		},
+	}
+
+	_, edits := buildEdits(t, src, metadata)
+	if len(edits) != 1 {
+		t.Fatalf("got %d edits, want 1", len(edits))
+	}
+	if edits[0].newText != "" {
+		t.Errorf("newText = %q, want empty deletion", edits[0].newText)
+	}
+}
+
+// TestDeclEdit_MultipleUnusedSpecsShareOneEdit is a regression test: two
+// unused consts pruned from the same block must collapse to a single
+// removalEdit, not one per name each independently claiming to replace the
+// whole decl — two such edits at the same range would be redundant at
+// best, and the earlier one would misleadingly suggest removing "a" alone
+// keeps "b".
+func TestDeclEdit_MultipleUnusedSpecsShareOneEdit(t *testing.T) {
+	const src = `package p
+
+const (
+	a    = 1
+	b    = 2
+	used = 3
+)
+`
+	metadata := &rewrite.FileMetadata{
+		Subjects: map[rewrite.Subject]struct{}{
+			{Name: "a", Kind: rewrite.Const}: {},
+			{Name: "b", Kind: rewrite.Const}: {},
+		},
+	}
+
+	_, edits := buildEdits(t, src, metadata)
+	if len(edits) != 1 {
+		t.Fatalf("got %d edits, want 1 shared edit; edits = %+v", len(edits), edits)
+	}
+	if len(edits[0].removals) != 2 {
+		t.Fatalf("got %d removals in the shared edit, want 2", len(edits[0].removals))
+	}
+
+	title := removalTitle(edits[0].removals)
+	const wantTitle = `Remove unused consts "a", "b"`
+	if title != wantTitle {
+		t.Errorf("removalTitle() = %q, want %q", title, wantTitle)
+	}
+}
+
+// TestDeclEdit_KeepsFloatingComment is a regression test: a comment that
+// isn't attached to any surviving spec (sitting between a pruned spec and
+// a kept one) must not be dropped when the decl is reformatted.
+func TestDeclEdit_KeepsFloatingComment(t *testing.T) {
+	const src = `package p
+
+var (
+	used = 3
+	a    = 1
+	// trailing floating comment
+)
+`
+	metadata := &rewrite.FileMetadata{
+		Subjects: map[rewrite.Subject]struct{}{
+			{Name: "a", Kind: rewrite.Var}: {},
+		},
+	}
+
+	_, edits := buildEdits(t, src, metadata)
+	if len(edits) != 1 {
+		t.Fatalf("got %d edits, want 1", len(edits))
+	}
+
+	const want = `var (
+	used = 3
+
+	// trailing floating comment
+)`
+	if edits[0].newText != want {
+		t.Errorf("newText = %q, want %q", edits[0].newText, want)
+	}
+}
@@ -0,0 +1,110 @@
+// Package config loads the keep-list and reachability-root escape hatch
+// users configure via .deadcode.yaml, so the tool doesn't have to be
+// right 100% of the time about what's reachable before it's safe to run
+// unattended: a name matching Keep is never removed, and a symbol listed
+// in Roots is always treated as alive by the VTA backend.
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultKeep are the keep patterns applied when no .deadcode.yaml is
+// present. They subsume the tool's old hard-coded checks: test/benchmark/
+// fuzz/example functions, which go test itself calls by reflection, and
+// main, which the staticcheck backend already special-cased.
+var DefaultKeep = []string{
+	`^Test`,
+	`^Benchmark`,
+	`^Fuzz`,
+	`^Example`,
+	`^main$`,
+}
+
+// DefaultSkipFiles are the skip-file patterns applied when no
+// .deadcode.yaml is present. They subsume the tool's old hard-coded
+// `generate` filename check: files produced by `//go:generate` are
+// regenerated from a source of truth this tool can't see, so they're
+// never rewritten.
+var DefaultSkipFiles = []string{
+	`generate`,
+}
+
+// Config is the contents of .deadcode.yaml.
+type Config struct {
+	// Keep is a list of regexes matched against unqualified names; any
+	// match is never removed, regardless of what the backend reported.
+	Keep []string `yaml:"keep"`
+	// Roots is a list of fully-qualified symbols (e.g.
+	// "example.com/pkg.Foo" or "example.com/pkg.(*T).Method") to treat as
+	// alive when building the VTA call graph, even with no discoverable
+	// incoming edge.
+	Roots []string `yaml:"roots"`
+	// SkipFiles is a list of regexes matched against a candidate file's
+	// path; any match is never rewritten, regardless of what the backend
+	// reported for it.
+	SkipFiles []string `yaml:"skip_files"`
+}
+
+// Load reads and parses the .deadcode.yaml at path. A missing file is not
+// an error: it returns the default config so callers don't need a
+// separate "file not found" branch.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Config{Keep: DefaultKeep, SkipFiles: DefaultSkipFiles}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	// A user's keep/skip_files list adds to the defaults rather than
+	// replacing them, so protecting one extra name doesn't reopen the
+	// test/main/generate guards the defaults exist for.
+	cfg.Keep = append(append([]string{}, DefaultKeep...), cfg.Keep...)
+	cfg.SkipFiles = append(append([]string{}, DefaultSkipFiles...), cfg.SkipFiles...)
+	return cfg, nil
+}
+
+// CompiledKeep compiles Keep into regexps, so callers pay the compile cost
+// once instead of per candidate name.
+func (c *Config) CompiledKeep() ([]*regexp.Regexp, error) {
+	return compilePatterns("keep", c.Keep)
+}
+
+// CompiledSkipFiles compiles SkipFiles into regexps, so callers pay the
+// compile cost once instead of per candidate file.
+func (c *Config) CompiledSkipFiles() ([]*regexp.Regexp, error) {
+	return compilePatterns("skip_files", c.SkipFiles)
+}
+
+func compilePatterns(field string, patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s pattern %q: %w", field, p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// Matches reports whether name matches any of the compiled patterns —
+// CompiledKeep's or CompiledSkipFiles', depending on the caller.
+func Matches(patterns []*regexp.Regexp, name string) bool {
+	for _, re := range patterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
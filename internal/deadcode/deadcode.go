@@ -0,0 +1,306 @@
+// Package deadcode builds a whole-program call graph with VTA (seeded from
+// CHA) and reports functions, methods, and named types that are never
+// reached from a set of known-alive roots. It is the whole-program
+// counterpart to the per-package staticcheck scan in the main package: it
+// catches cross-package dead code that staticcheck's unused checker, which
+// only ever looks at one package at a time, cannot see.
+package deadcode
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/callgraph/vta"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// SubjectKind mirrors the staticcheck "func"/"type"/"method" distinction
+// used by the main package's subjectType, but lives here so this package
+// has no dependency on package main.
+type SubjectKind string
+
+const (
+	Func   SubjectKind = "func"
+	Type   SubjectKind = "type"
+	Method SubjectKind = "method"
+)
+
+// Subject is one unreachable func, method, or named type.
+type Subject struct {
+	Name string
+	Kind SubjectKind
+}
+
+// FileResult collects the unreachable subjects declared in a single file.
+type FileResult struct {
+	File     string
+	Subjects []Subject
+}
+
+// Analyze loads the packages matching patterns, builds a whole-program SSA
+// call graph refined with VTA, and returns the subjects that are not
+// reachable from main, init, test entry points, exported package-level
+// funcs (only when the loaded packages form a library with no `main` of
+// its own), or extraRoots — fully-qualified symbols (e.g.
+// "example.com/pkg.Foo" or "example.com/pkg.(*T).Method") from the
+// `roots:` list in .deadcode.yaml, for cases where a symbol is only
+// invoked through mechanisms the call graph can't see, such as reflection
+// or a plugin entry point — keyed by the file they're declared in.
+//
+// Packages are loaded with Tests: true so that a symbol only reached from
+// a _test.go file counts as reachable; go/packages compiles each tested
+// package twice (once without its test files, once as the "p [p.test]"
+// variant with them), so a production declaration and its test-variant
+// copy are distinct *ssa.Function values even though they share a
+// token.Pos — unreachableSubjects accounts for that by matching
+// reachability on position rather than ssa.Function identity.
+func Analyze(patterns []string, extraRoots []string) ([]FileResult, error) {
+	cfg := &packages.Config{Mode: packages.LoadAllSyntax, Tests: true}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, fmt.Errorf("loading packages: %w", err)
+	}
+	if packages.PrintErrors(pkgs) > 0 {
+		return nil, fmt.Errorf("errors loading packages matching %v", patterns)
+	}
+
+	prog, ssaPkgs := ssautil.AllPackages(pkgs, ssa.BuilderMode(0))
+	prog.Build()
+
+	allFuncs := ssautil.AllFunctions(prog)
+	chaGraph := cha.CallGraph(prog)
+	vtaGraph := vta.CallGraph(allFuncs, chaGraph)
+	vtaGraph.DeleteSyntheticNodes()
+
+	roots := rootFunctions(ssaPkgs, isLibrary(pkgs))
+	roots = append(roots, extraRootFunctions(allFuncs, extraRoots)...)
+	reachable := reachableFrom(vtaGraph, roots)
+
+	keepInterfaceMethods(prog, reachable)
+
+	return unreachableSubjects(pkgs, ssaPkgs, reachable), nil
+}
+
+// isProductionPackage reports whether p is the ordinary, non-test
+// compilation of a package rather than one of the synthetic variants
+// Tests: true adds: the "p [p.test]"/"p_test [p.test]" copies recompiled
+// with test files, and the "p.test" generated test-binary main. Those
+// variants exist to seed reachability roots and call edges, not to have
+// their own declarations reported or rewritten.
+func isProductionPackage(p *packages.Package) bool {
+	return !strings.Contains(p.ID, " [") && !strings.HasSuffix(p.PkgPath, ".test")
+}
+
+// rootFunctions returns the set of ssa.Functions that must be treated as
+// alive regardless of incoming edges: main, init, test/benchmark/example
+// entry points, and — only when the loaded package set has no `main`
+// package, i.e. it's actually a library — every exported package-level
+// func, since a library may be consumed by code outside this program. In
+// the ordinary main+internal-packages layout that isn't true: an internal
+// package's exported funcs are only reachable through main, so treating
+// them all as roots would defeat the whole-program analysis.
+func rootFunctions(pkgs []*ssa.Package, isLibrary bool) []*ssa.Function {
+	var roots []*ssa.Function
+	for _, pkg := range pkgs {
+		if pkg == nil {
+			continue
+		}
+		for _, member := range pkg.Members {
+			fn, ok := member.(*ssa.Function)
+			if !ok {
+				continue
+			}
+			switch {
+			case fn.Name() == "main" || fn.Name() == "init":
+				roots = append(roots, fn)
+			case isLibrary && fn.Object() != nil && fn.Object().Exported():
+				roots = append(roots, fn)
+			case isTestEntryPoint(fn.Name()):
+				roots = append(roots, fn)
+			}
+		}
+	}
+	return roots
+}
+
+// isLibrary reports whether none of the loaded production packages is a
+// `main` package: a program with a main entry point can have every
+// reachable path traced from it, but a library has no entry point of its
+// own, so its exported API must be treated as a set of implicit roots.
+// The generated "p.test" test-binary package is always named main, so
+// it's excluded here — otherwise running with Tests: true would make
+// every library look like a program the moment it has any tests.
+func isLibrary(pkgs []*packages.Package) bool {
+	for _, pkg := range pkgs {
+		if pkg != nil && isProductionPackage(pkg) && pkg.Name == "main" {
+			return false
+		}
+	}
+	return true
+}
+
+// extraRootFunctions matches the `roots:` list from .deadcode.yaml —
+// fully-qualified symbols such as "example.com/pkg.Foo" or
+// "example.com/pkg.(*T).Method" — against every function and method in
+// the program (fn.RelString(nil) renders a function in exactly that
+// form), so a root can name a method as well as a free function.
+func extraRootFunctions(allFuncs map[*ssa.Function]bool, extraRoots []string) []*ssa.Function {
+	if len(extraRoots) == 0 {
+		return nil
+	}
+	want := make(map[string]bool, len(extraRoots))
+	for _, r := range extraRoots {
+		want[r] = true
+	}
+
+	var roots []*ssa.Function
+	for fn := range allFuncs {
+		if want[fn.RelString(nil)] {
+			roots = append(roots, fn)
+		}
+	}
+	return roots
+}
+
+func isTestEntryPoint(name string) bool {
+	for _, prefix := range []string{"Test", "Benchmark", "Fuzz", "Example"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// reachableFrom walks g from roots and returns every function node reached.
+func reachableFrom(g *callgraph.Graph, roots []*ssa.Function) map[*ssa.Function]bool {
+	reachable := map[*ssa.Function]bool{}
+	var visit func(n *callgraph.Node)
+	visit = func(n *callgraph.Node) {
+		if n == nil || n.Func == nil || reachable[n.Func] {
+			return
+		}
+		reachable[n.Func] = true
+		for _, edge := range n.Out {
+			visit(edge.Callee)
+		}
+	}
+	for _, root := range roots {
+		visit(g.CreateNode(root))
+	}
+	return reachable
+}
+
+// keepInterfaceMethods marks every method of every concrete type as
+// reachable if that type satisfies an interface reached by the program:
+// the method may only be invoked through a dynamic dispatch the call
+// graph cannot see syntactically.
+func keepInterfaceMethods(prog *ssa.Program, reachable map[*ssa.Function]bool) {
+	reachableIfaces := map[*types.Interface]bool{}
+	for fn := range reachable {
+		for _, param := range fn.Params {
+			if iface, ok := param.Type().Underlying().(*types.Interface); ok {
+				reachableIfaces[iface] = true
+			}
+		}
+	}
+	if len(reachableIfaces) == 0 {
+		return
+	}
+
+	for _, rt := range prog.RuntimeTypes() {
+		mset := prog.MethodSets.MethodSet(rt)
+		for iface := range reachableIfaces {
+			if !types.Implements(rt, iface) {
+				continue
+			}
+			for i := 0; i < mset.Len(); i++ {
+				if fn := prog.MethodValue(mset.At(i)); fn != nil {
+					reachable[fn] = true
+				}
+			}
+		}
+	}
+}
+
+// unreachableSubjects walks package-level members of the production
+// packages and reports the ones absent from reachable, grouped by
+// declaring file. pkgs and ssaPkgs are index-aligned, as returned by
+// ssautil.AllPackages.
+//
+// Reachability is additionally matched by token.Pos: a production
+// declaration and its "p [p.test]"/"p_test [p.test]" test-variant copy
+// are distinct *ssa.Function values sharing one token.Pos (go/packages
+// parses each file once and reuses the AST across variants), so a
+// function only called from a test, which the call graph only sees
+// reached through the test variant's copy, is still recognized as
+// reachable here.
+func unreachableSubjects(pkgs []*packages.Package, ssaPkgs []*ssa.Package, reachable map[*ssa.Function]bool) []FileResult {
+	reachablePos := make(map[token.Pos]bool, len(reachable))
+	for fn := range reachable {
+		if fn.Pos().IsValid() {
+			reachablePos[fn.Pos()] = true
+		}
+	}
+	isReachable := func(fn *ssa.Function) bool {
+		return reachable[fn] || reachablePos[fn.Pos()]
+	}
+
+	byFile := map[string]*FileResult{}
+	order := []string{}
+
+	add := func(fset *token.FileSet, pos token.Pos, s Subject) {
+		file := fset.Position(pos).Filename
+		if file == "" {
+			return
+		}
+		res, ok := byFile[file]
+		if !ok {
+			res = &FileResult{File: file}
+			byFile[file] = res
+			order = append(order, file)
+		}
+		res.Subjects = append(res.Subjects, s)
+	}
+
+	for i, pkg := range ssaPkgs {
+		if pkg == nil || !isProductionPackage(pkgs[i]) {
+			continue
+		}
+		fset := pkg.Prog.Fset
+		for _, member := range pkg.Members {
+			switch m := member.(type) {
+			case *ssa.Function:
+				if isReachable(m) {
+					continue
+				}
+				add(fset, m.Pos(), Subject{Name: m.Name(), Kind: Func})
+			case *ssa.Type:
+				named, ok := m.Type().(*types.Named)
+				if !ok {
+					continue
+				}
+				add(fset, named.Obj().Pos(), Subject{Name: named.Obj().Name(), Kind: Type})
+				mset := pkg.Prog.MethodSets.MethodSet(named)
+				for j := 0; j < mset.Len(); j++ {
+					fn := pkg.Prog.MethodValue(mset.At(j))
+					if fn == nil || isReachable(fn) {
+						continue
+					}
+					add(fset, fn.Pos(), Subject{Name: fmt.Sprintf("%s.%s", named.Obj().Name(), fn.Name()), Kind: Method})
+				}
+			}
+		}
+	}
+
+	results := make([]FileResult, 0, len(order))
+	for _, file := range order {
+		results = append(results, *byFile[file])
+	}
+	return results
+}
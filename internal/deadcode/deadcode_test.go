@@ -0,0 +1,37 @@
+package deadcode
+
+import "testing"
+
+// TestAnalyze_TestOnlyCaller is a regression test: Helper is exported from
+// a main package and called only from a _test.go file, which must keep it
+// off the unreachable list, while Dead, which no one calls at all, must
+// still be reported.
+func TestAnalyze_TestOnlyCaller(t *testing.T) {
+	results, err := Analyze([]string{"github.com/ChenJesse/go-deadcode/internal/deadcode/testdata/testonly"}, nil)
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+
+	var names []string
+	for _, res := range results {
+		for _, s := range res.Subjects {
+			names = append(names, s.Name)
+		}
+	}
+
+	for _, name := range names {
+		if name == "Helper" {
+			t.Errorf("Analyze() reported %q as unreachable, but it's called from TestHelper", name)
+		}
+	}
+
+	found := false
+	for _, name := range names {
+		if name == "Dead" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Analyze() did not report unused Dead; got subjects %v", names)
+	}
+}
@@ -0,0 +1,11 @@
+// Package main is a fixture for TestAnalyze_TestOnlyCaller: Helper is
+// reachable only from main_test.go, and Dead is reachable from nowhere.
+package main
+
+func main() {}
+
+// Helper is never called from production code, only from TestHelper.
+func Helper() int { return 42 }
+
+// Dead is never called at all, from production or test code.
+func Dead() int { return 0 }
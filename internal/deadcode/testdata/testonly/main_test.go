@@ -0,0 +1,9 @@
+package main
+
+import "testing"
+
+func TestHelper(t *testing.T) {
+	if got := Helper(); got != 42 {
+		t.Fatalf("Helper() = %d, want 42", got)
+	}
+}
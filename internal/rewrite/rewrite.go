@@ -0,0 +1,312 @@
+// Package rewrite contains the unused-subject matching and AST-pruning
+// logic shared by the in-place CLI rewriter (package main) and the LSP
+// server (cmd/go-deadcode-lsp): both need to turn a set of "this name is
+// unused" subjects into the exact declarations or spec names to delete,
+// they just do different things with the result — one formats the whole
+// file back to disk, the other turns each Removal into a WorkspaceEdit.
+package rewrite
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// keepPragmas are directive comments that mark a decl as implicitly kept
+// even when it looks unused to the backend: the symbol may only be
+// referenced from outside what either backend can see, such as a cgo
+// caller (//export) or a linker-resolved alias (//go:linkname).
+var keepPragmas = []string{"//go:linkname", "//export ", "//go:uintptrescapes"}
+
+// hasKeepPragma reports whether any comment directly associated with decl
+// is one of keepPragmas.
+func hasKeepPragma(cmap ast.CommentMap, decl ast.Node) bool {
+	for _, group := range cmap[decl] {
+		for _, c := range group.List {
+			for _, pragma := range keepPragmas {
+				if strings.HasPrefix(c.Text, pragma) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// SubjectKind identifies what flavor of declaration a Subject names.
+type SubjectKind string
+
+const (
+	Func   SubjectKind = "func"
+	Type   SubjectKind = "type"
+	Method SubjectKind = "method"
+	Var    SubjectKind = "var"
+	Const  SubjectKind = "const"
+)
+
+// Subject is one name flagged as unused, along with its kind.
+type Subject struct {
+	Name string
+	Kind SubjectKind
+}
+
+// FileMetadata is the set of unused subjects declared in a single file.
+type FileMetadata struct {
+	FileName string
+	Subjects map[Subject]struct{}
+}
+
+// Removal is one declaration, or one name pruned out of a grouped
+// var/const spec, that Rewrite deleted. Node is the original AST node —
+// a *ast.FuncDecl, *ast.GenDecl, *ast.TypeSpec, *ast.ValueSpec, or
+// *ast.Ident — and keeps a valid Pos()/End() even after being detached
+// from the tree, so callers that only want ranges (the LSP server) can
+// use it without caring that Rewrite already unlinked it.
+type Removal struct {
+	Kind SubjectKind
+	Name string
+	Node ast.Node
+}
+
+// Rewrite mutates node in place, dropping every top-level FuncDecl,
+// GenDecl, TypeSpec, and var/const name that metadata flags as unused,
+// and filtering node.Comments through cmap so doc comments go with their
+// declarations. It returns a Removal for everything it dropped.
+func Rewrite(node *ast.File, cmap ast.CommentMap, metadata *FileMetadata) []Removal {
+	var removed []Removal
+	var keptDecls []ast.Decl
+	for _, decl := range node.Decls {
+		if hasKeepPragma(cmap, decl) {
+			keptDecls = append(keptDecls, decl)
+			continue
+		}
+
+		if fn, ok := decl.(*ast.FuncDecl); ok {
+			if r, drop := matchFunc(fn, metadata); drop {
+				removed = append(removed, r)
+				continue
+			}
+		}
+
+		if gn, ok := decl.(*ast.GenDecl); ok {
+			kept, gnRemoved := filterGenDeclSpecs(gn, metadata)
+			removed = append(removed, gnRemoved...)
+			if !kept {
+				continue
+			}
+		}
+
+		keptDecls = append(keptDecls, decl)
+	}
+	node.Decls = keptDecls
+	node.Comments = cmap.Filter(node).Comments()
+	return removed
+}
+
+// matchFunc reports whether fn should be dropped: either it's directly
+// named as unused, or it's a method whose receiver type (or the method
+// itself) was flagged.
+func matchFunc(fn *ast.FuncDecl, metadata *FileMetadata) (Removal, bool) {
+	if _, ok := metadata.Subjects[Subject{fn.Name.Name, Func}]; ok {
+		return Removal{Func, fn.Name.Name, fn}, true
+	}
+
+	if fn.Recv == nil {
+		return Removal{}, false
+	}
+
+	var receiverTypeName string
+	switch typ := fn.Recv.List[0].Type.(type) {
+	case *ast.StarExpr:
+		if si, ok := typ.X.(*ast.Ident); ok {
+			receiverTypeName = si.Name
+		}
+	case *ast.Ident:
+		receiverTypeName = typ.Name
+	}
+
+	if _, ok := metadata.Subjects[Subject{receiverTypeName, Type}]; ok {
+		return Removal{Method, fmt.Sprintf("%s.%s", receiverTypeName, fn.Name.Name), fn}, true
+	}
+	methodName := fmt.Sprintf("%s.%s", receiverTypeName, fn.Name.Name)
+	if _, ok := metadata.Subjects[Subject{methodName, Method}]; ok {
+		return Removal{Method, methodName, fn}, true
+	}
+	return Removal{}, false
+}
+
+// filterGenDeclSpecs rewrites gn.Specs in place, dropping unused type
+// specs wholesale and pruning unused names out of var/const ValueSpecs so
+// that a grouped `var (...)` or `const (...)` block only loses the names
+// that were actually flagged. It reports whether any specs remain.
+//
+// A const group that relies on implicit iota repetition (`const ( A =
+// iota; B; C )`) is special-cased: dropping any ValueSpec but the last
+// ones would shift the iota value of every spec that follows it, so in
+// such a group only a trailing run of fully-unused specs is ever pruned.
+func filterGenDeclSpecs(gn *ast.GenDecl, metadata *FileMetadata) (bool, []Removal) {
+	protectIota := isIotaGroup(gn)
+	trailingUnused := trailingUnusedValueSpecs(gn, metadata, protectIota)
+
+	var keptSpecs []ast.Spec
+	var removed []Removal
+	for i, spec := range gn.Specs {
+		switch s := spec.(type) {
+		case *ast.TypeSpec:
+			if _, ok := metadata.Subjects[Subject{s.Name.Name, Type}]; ok {
+				removed = append(removed, Removal{Type, s.Name.Name, s})
+				continue
+			}
+			keptSpecs = append(keptSpecs, s)
+		case *ast.ValueSpec:
+			if protectIota && !trailingUnused[i] {
+				keptSpecs = append(keptSpecs, s)
+				continue
+			}
+			kind := Var
+			if gn.Tok == token.CONST {
+				kind = Const
+			}
+			kept, specRemoved := filterValueSpecNames(s, metadata, kind)
+			removed = append(removed, specRemoved...)
+			if kept {
+				keptSpecs = append(keptSpecs, s)
+			}
+		default:
+			keptSpecs = append(keptSpecs, spec)
+		}
+	}
+	gn.Specs = keptSpecs
+	return len(keptSpecs) > 0, removed
+}
+
+// isIotaGroup reports whether gn is a const group that relies on iota:
+// either a spec's Values expression references iota directly, or a spec
+// after the first omits Values entirely, which repeats the previous
+// spec's (iota-bearing) Values.
+func isIotaGroup(gn *ast.GenDecl) bool {
+	if gn.Tok != token.CONST {
+		return false
+	}
+	for i, spec := range gn.Specs {
+		vs, ok := spec.(*ast.ValueSpec)
+		if !ok {
+			continue
+		}
+		if i > 0 && len(vs.Values) == 0 {
+			return true
+		}
+		if exprsReferenceIota(vs.Values) {
+			return true
+		}
+	}
+	return false
+}
+
+// exprsReferenceIota reports whether any of exprs contains the iota
+// identifier.
+func exprsReferenceIota(exprs []ast.Expr) bool {
+	for _, e := range exprs {
+		found := false
+		ast.Inspect(e, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && id.Name == "iota" {
+				found = true
+				return false
+			}
+			return true
+		})
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// trailingUnusedValueSpecs returns, when protectIota is set, which
+// indices into gn.Specs form the contiguous run of fully-unused
+// ValueSpecs at the end of the list — the only specs an iota-bearing
+// group can safely drop without shifting a kept spec's value. It's nil
+// when protectIota is false, since no restriction applies.
+func trailingUnusedValueSpecs(gn *ast.GenDecl, metadata *FileMetadata, protectIota bool) map[int]bool {
+	if !protectIota {
+		return nil
+	}
+	trailing := map[int]bool{}
+	for i := len(gn.Specs) - 1; i >= 0; i-- {
+		vs, ok := gn.Specs[i].(*ast.ValueSpec)
+		if !ok || !allNamesUnused(vs, metadata, Const) {
+			break
+		}
+		trailing[i] = true
+	}
+	return trailing
+}
+
+// allNamesUnused reports whether every name in vs is flagged as unused.
+func allNamesUnused(vs *ast.ValueSpec, metadata *FileMetadata, kind SubjectKind) bool {
+	for _, name := range vs.Names {
+		if _, ok := metadata.Subjects[Subject{name.Name, kind}]; !ok {
+			return false
+		}
+	}
+	return len(vs.Names) > 0
+}
+
+// filterValueSpecNames drops the unused names (and, when Values lines up
+// one-to-one with Names, their corresponding initializer expressions)
+// from a var/const ValueSpec. When Values doesn't align with Names — e.g.
+// a single multi-value call like `a, b := f()` — pruning an expression
+// could change what the remaining names evaluate to, so the spec is left
+// untouched to preserve evaluation order. It reports whether any names
+// remain.
+func filterValueSpecNames(s *ast.ValueSpec, metadata *FileMetadata, kind SubjectKind) (bool, []Removal) {
+	hasValues := len(s.Values) > 0
+	canPruneValues := !hasValues || len(s.Values) == len(s.Names)
+	if !canPruneValues && anyNameUnused(s, metadata, kind) {
+		// Values doesn't align with Names — e.g. a single multi-value
+		// call like `a, b := f()` — so dropping one name without its
+		// value would change what the remaining names evaluate to.
+		// Leave the whole spec untouched rather than pruning it.
+		return true, nil
+	}
+
+	var keptNames []*ast.Ident
+	var keptValues []ast.Expr
+	var removed []Removal
+	for i, name := range s.Names {
+		if _, ok := metadata.Subjects[Subject{name.Name, kind}]; ok {
+			removed = append(removed, Removal{kind, name.Name, name})
+			continue
+		}
+		keptNames = append(keptNames, name)
+		if hasValues {
+			keptValues = append(keptValues, s.Values[i])
+		}
+	}
+
+	if len(removed) == 0 {
+		// Nothing flagged in this spec; leave it untouched.
+		return true, nil
+	}
+	if len(keptNames) == 0 {
+		return false, removed
+	}
+
+	s.Names = keptNames
+	if hasValues {
+		s.Values = keptValues
+	}
+	return true, removed
+}
+
+// anyNameUnused reports whether at least one name in s is flagged as
+// unused.
+func anyNameUnused(s *ast.ValueSpec, metadata *FileMetadata, kind SubjectKind) bool {
+	for _, name := range s.Names {
+		if _, ok := metadata.Subjects[Subject{name.Name, kind}]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package rewrite
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+// rewriteSource parses src, runs Rewrite against metadata, and returns the
+// formatted result.
+func rewriteSource(t *testing.T, src string, metadata *FileMetadata) string {
+	t.Helper()
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, "test.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	cmap := ast.NewCommentMap(fset, node, node.Comments)
+	Rewrite(node, cmap, metadata)
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, node); err != nil {
+		t.Fatalf("format.Node: %v", err)
+	}
+	return buf.String()
+}
+
+func TestRewrite_GroupedConstExplicitValues(t *testing.T) {
+	const src = `package p
+
+const (
+	A = 1
+	B = 2
+)
+`
+	metadata := &FileMetadata{
+		Subjects: map[Subject]struct{}{
+			{Name: "B", Kind: Const}: {},
+		},
+	}
+
+	got := rewriteSource(t, src, metadata)
+	const want = `package p
+
+const (
+	A = 1
+)
+`
+	if got != want {
+		t.Errorf("Rewrite() = %q, want %q", got, want)
+	}
+}
+
+func TestRewrite_IotaGroupKeepsNonTrailingUnused(t *testing.T) {
+	const src = `package p
+
+const (
+	A = iota
+	B
+	C
+)
+`
+	// Only B is flagged, but B isn't the trailing spec in an iota-bearing
+	// group, so pruning it would shift C's iota value from 2 to 1: the
+	// spec must be left in place.
+	metadata := &FileMetadata{
+		Subjects: map[Subject]struct{}{
+			{Name: "B", Kind: Const}: {},
+		},
+	}
+
+	got := rewriteSource(t, src, metadata)
+	if got != src {
+		t.Errorf("Rewrite() = %q, want unchanged %q", got, src)
+	}
+}
+
+func TestRewrite_IotaGroupPrunesTrailingUnused(t *testing.T) {
+	const src = `package p
+
+const (
+	A = iota
+	B
+	C
+)
+`
+	// C is the trailing spec, so dropping it doesn't shift any kept
+	// spec's iota value.
+	metadata := &FileMetadata{
+		Subjects: map[Subject]struct{}{
+			{Name: "C", Kind: Const}: {},
+		},
+	}
+
+	got := rewriteSource(t, src, metadata)
+	const want = `package p
+
+const (
+	A = iota
+	B
+)
+`
+	if got != want {
+		t.Errorf("Rewrite() = %q, want %q", got, want)
+	}
+}
+
+func TestRewrite_MultiValueCallLeavesSpecIntact(t *testing.T) {
+	const src = `package p
+
+func f() (int, int) { return 1, 2 }
+
+var unusedA, usedB = f()
+`
+	// unusedA and usedB share a single multi-value call with no
+	// one-to-one Names/Values correspondence, so dropping unusedA alone
+	// would leave `usedB = f()` in single-value context and fail to
+	// compile: the spec must be left untouched.
+	metadata := &FileMetadata{
+		Subjects: map[Subject]struct{}{
+			{Name: "unusedA", Kind: Var}: {},
+		},
+	}
+
+	got := rewriteSource(t, src, metadata)
+	if got != src {
+		t.Errorf("Rewrite() = %q, want unchanged %q", got, src)
+	}
+}
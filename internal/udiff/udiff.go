@@ -0,0 +1,196 @@
+// Package udiff computes a unified line diff between two byte slices. It
+// exists so the dry-run flag in the main package can print a standard
+// ---/+++ unified diff without depending on golang.org/x/tools/internal/diff,
+// which is off-limits to anything outside that module.
+package udiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Unified returns a standard unified diff (as produced by `diff -u`)
+// between before and after, with path used in the "---"/"+++" headers for
+// both sides. It returns "" if before and after are identical.
+func Unified(path string, before, after []byte) string {
+	a := splitLines(string(before))
+	b := splitLines(string(after))
+	ops := diffLines(a, b)
+	if len(ops) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n", path)
+	fmt.Fprintf(&sb, "+++ %s\n", path)
+	for _, h := range hunksFromOps(ops, a, b) {
+		h.writeTo(&sb)
+	}
+	return sb.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type op struct {
+	kind opKind
+	aIdx int
+	bIdx int
+}
+
+// diffLines runs a classic O(N*M) longest-common-subsequence diff over the
+// two line slices and returns the resulting equal/delete/insert ops in
+// order. Source files are small enough that the quadratic cost doesn't
+// matter in practice.
+func diffLines(a, b []string) []op {
+	n, m := len(a), len(b)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []op
+	i, j := 0, 0
+	equal := true
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{opEqual, i, j})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{opDelete, i, -1})
+			i++
+			equal = false
+		default:
+			ops = append(ops, op{opInsert, -1, j})
+			j++
+			equal = false
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, op{opDelete, i, -1})
+		equal = false
+	}
+	for ; j < m; j++ {
+		ops = append(ops, op{opInsert, -1, j})
+		equal = false
+	}
+
+	if equal {
+		return nil
+	}
+	return ops
+}
+
+const contextLines = 3
+
+type hunk struct {
+	aStart, bStart int // 1-based first line number on each side
+	aCount, bCount int
+	lines          []string // each prefixed with ' ', '-', or '+'
+}
+
+func (h hunk) writeTo(sb *strings.Builder) {
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", h.aStart, h.aCount, h.bStart, h.bCount)
+	for _, line := range h.lines {
+		sb.WriteString(line)
+	}
+}
+
+// hunksFromOps groups the op stream into unified-diff hunks: each run of
+// changes is padded with up to contextLines of surrounding equal lines,
+// and runs whose padded context windows overlap are merged into one hunk.
+func hunksFromOps(ops []op, a, b []string) []hunk {
+	// windows[k] = [lo, hi) range of op indices to include for change run k.
+	var windows [][2]int
+	for i := 0; i < len(ops); {
+		if ops[i].kind == opEqual {
+			i++
+			continue
+		}
+		j := i
+		for j < len(ops) && ops[j].kind != opEqual {
+			j++
+		}
+		lo := i - contextLines
+		if lo < 0 {
+			lo = 0
+		}
+		hi := j + contextLines
+		if hi > len(ops) {
+			hi = len(ops)
+		}
+		if len(windows) > 0 && lo <= windows[len(windows)-1][1] {
+			windows[len(windows)-1][1] = hi
+		} else {
+			windows = append(windows, [2]int{lo, hi})
+		}
+		i = j
+	}
+
+	var hunks []hunk
+	for _, w := range windows {
+		hunks = append(hunks, buildHunk(ops[w[0]:w[1]], a, b))
+	}
+	return hunks
+}
+
+func buildHunk(ops []op, a, b []string) hunk {
+	h := hunk{}
+	haveAStart, haveBStart := false, false
+	for _, o := range ops {
+		switch o.kind {
+		case opEqual:
+			if !haveAStart {
+				h.aStart, haveAStart = o.aIdx+1, true
+			}
+			if !haveBStart {
+				h.bStart, haveBStart = o.bIdx+1, true
+			}
+			h.lines = append(h.lines, " "+a[o.aIdx])
+			h.aCount++
+			h.bCount++
+		case opDelete:
+			if !haveAStart {
+				h.aStart, haveAStart = o.aIdx+1, true
+			}
+			h.lines = append(h.lines, "-"+a[o.aIdx])
+			h.aCount++
+		case opInsert:
+			if !haveBStart {
+				h.bStart, haveBStart = o.bIdx+1, true
+			}
+			h.lines = append(h.lines, "+"+b[o.bIdx])
+			h.bCount++
+		}
+	}
+	return h
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"go/ast"
 	"go/format"
@@ -15,26 +16,13 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
-)
-
-type subjectType string
 
-const (
-	unusedFunc   subjectType = "unusedFunc"
-	unusedType   subjectType = "unusedType"
-	unusedMethod subjectType = "unusedMethod"
+	"github.com/ChenJesse/go-deadcode/internal/config"
+	"github.com/ChenJesse/go-deadcode/internal/deadcode"
+	"github.com/ChenJesse/go-deadcode/internal/rewrite"
+	"github.com/ChenJesse/go-deadcode/internal/udiff"
 )
 
-type unusedSubject struct {
-	subjectName string
-	typ         subjectType
-}
-
-type unusedFileMetadata struct {
-	fileName string
-	subjects map[unusedSubject]struct{}
-}
-
 type StaticCheckerJson struct {
 	Code     string `json:"code"`
 	Severity string `json:"severity"`
@@ -52,16 +40,85 @@ type StaticCheckerJson struct {
 }
 
 func main() {
-	if len(os.Args) != 2 {
-		log.Fatal("The file with the staticcheck tool output must be passed in.")
+	mode := flag.String("mode", "staticcheck", `analysis backend to use: "staticcheck" (default, reads a staticcheck JSON report) or "vta" (whole-program call-graph analysis, no staticcheck run required)`)
+	var dryRun bool
+	flag.BoolVar(&dryRun, "n", false, "preview removals as a unified diff instead of rewriting files (shorthand for -diff)")
+	flag.BoolVar(&dryRun, "diff", false, "preview removals as a unified diff instead of rewriting files")
+	jsonOut := flag.Bool("json", false, "emit a machine-readable JSON report of removed declarations instead of rewriting files")
+	configPath := flag.String("config", ".deadcode.yaml", "path to a keep-list/roots config file (see .deadcode.yaml); defaults apply if it doesn't exist")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	keep, err := cfg.CompiledKeep()
+	if err != nil {
+		log.Fatal(err)
 	}
-	staticcheckFile, err := os.Open(os.Args[1])
+	skipFiles, err := cfg.CompiledSkipFiles()
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	var fileSet map[string]struct{}
+	var unusedMetadata map[string]*rewrite.FileMetadata
+
+	switch *mode {
+	case "vta":
+		patterns := flag.Args()
+		if len(patterns) == 0 {
+			patterns = []string{"./..."}
+		}
+		fileSet, unusedMetadata = loadVTAMetadata(patterns, cfg.Roots, keep)
+	case "staticcheck":
+		if flag.NArg() != 1 {
+			log.Fatal("The file with the staticcheck tool output must be passed in.")
+		}
+		fileSet, unusedMetadata = loadStaticcheckMetadata(flag.Arg(0), keep)
+	default:
+		log.Fatalf("unknown -mode %q: want \"staticcheck\" or \"vta\"", *mode)
+	}
+
+	handleUnused(fileSet, unusedMetadata, skipFiles, outputOptions{DryRun: dryRun, JSON: *jsonOut})
+}
+
+// outputOptions controls what handleUnused does with a rewritten file once
+// it's computed: write it in place (the default), print a unified diff, or
+// collect a JSON report. DryRun and JSON are mutually exclusive; JSON wins
+// if both are set.
+type outputOptions struct {
+	DryRun bool
+	JSON   bool
+}
+
+// removedItem describes one declaration (or grouped spec name) that was
+// dropped from a file, for the -json report.
+type removedItem struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	Line int    `json:"line"`
+}
+
+// fileReport is the -json report entry for a single rewritten file.
+type fileReport struct {
+	File    string        `json:"file"`
+	Removed []removedItem `json:"removed"`
+}
+
+// loadStaticcheckMetadata parses a newline-delimited JSON staticcheck
+// report (as produced by `staticcheck -f json`) and collects the "is
+// unused" violations into the file/subject maps handleUnused expects,
+// skipping any subject whose name matches a keep pattern.
+func loadStaticcheckMetadata(reportPath string, keep []*regexp.Regexp) (map[string]struct{}, map[string]*rewrite.FileMetadata) {
+	staticcheckFile, err := os.Open(reportPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer staticcheckFile.Close()
+
 	fileSet := map[string]struct{}{}
-	unusedMetadata := map[string]*unusedFileMetadata{}
+	unusedMetadata := map[string]*rewrite.FileMetadata{}
 	unusedRegexp := regexp.MustCompile(`(.*)\ (.*)\ is\ unused.*`)
 
 	scanner := bufio.NewScanner(staticcheckFile)
@@ -76,112 +133,143 @@ func main() {
 			typ, subject := m[1], m[2]
 			metadata := unusedMetadata[fileName]
 			if metadata == nil {
-				metadata = &unusedFileMetadata{
-					fileName: fileName,
-					subjects: map[unusedSubject]struct{}{},
+				metadata = &rewrite.FileMetadata{
+					FileName: fileName,
+					Subjects: map[rewrite.Subject]struct{}{},
 				}
 			}
 
+			if config.Matches(keep, subject) {
+				continue
+			}
+
 			switch typ {
 			case "func":
 				// If this function has a `.`, it is a method.
 				if strings.Contains(subject, ".") {
 					methodName := subject
-					metadata.subjects[unusedSubject{methodName, unusedMethod}] = struct{}{}
+					metadata.Subjects[rewrite.Subject{Name: methodName, Kind: rewrite.Method}] = struct{}{}
 					unusedMetadata[fileName] = metadata
 					fileSet[fileName] = struct{}{}
 					continue
 				}
 				funcName := subject
-				// Let's not delete main functions, they could be run manually.
-				if funcName == "main" {
-					continue
-				}
-				metadata.subjects[unusedSubject{funcName, unusedFunc}] = struct{}{}
+				metadata.Subjects[rewrite.Subject{Name: funcName, Kind: rewrite.Func}] = struct{}{}
 				unusedMetadata[fileName] = metadata
 				fileSet[fileName] = struct{}{}
 			case "type":
 				typeName := subject
-				metadata.subjects[unusedSubject{typeName, unusedType}] = struct{}{}
+				metadata.Subjects[rewrite.Subject{Name: typeName, Kind: rewrite.Type}] = struct{}{}
+				unusedMetadata[fileName] = metadata
+				fileSet[fileName] = struct{}{}
+			case "var":
+				varName := subject
+				metadata.Subjects[rewrite.Subject{Name: varName, Kind: rewrite.Var}] = struct{}{}
+				unusedMetadata[fileName] = metadata
+				fileSet[fileName] = struct{}{}
+			case "const":
+				constName := subject
+				metadata.Subjects[rewrite.Subject{Name: constName, Kind: rewrite.Const}] = struct{}{}
 				unusedMetadata[fileName] = metadata
 				fileSet[fileName] = struct{}{}
 			}
 		}
 	}
 
-	handleUnused(fileSet, unusedMetadata)
+	return fileSet, unusedMetadata
 }
 
-func handleUnused(fileSet map[string]struct{}, unusedMetadata map[string]*unusedFileMetadata) {
+// loadVTAMetadata runs the whole-program VTA call-graph analysis over the
+// packages matching patterns, seeded with the configured extra roots, and
+// reshapes its result into the same fileSet/unusedMetadata pair the
+// staticcheck path produces, so both backends funnel into the same
+// handleUnused rewriter. Subjects matching a keep pattern are dropped.
+func loadVTAMetadata(patterns []string, roots []string, keep []*regexp.Regexp) (map[string]struct{}, map[string]*rewrite.FileMetadata) {
+	results, err := deadcode.Analyze(patterns, roots)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fileSet := map[string]struct{}{}
+	unusedMetadata := map[string]*rewrite.FileMetadata{}
+
+	kindToSubjectKind := map[deadcode.SubjectKind]rewrite.SubjectKind{
+		deadcode.Func:   rewrite.Func,
+		deadcode.Type:   rewrite.Type,
+		deadcode.Method: rewrite.Method,
+	}
+
+	for _, res := range results {
+		metadata := &rewrite.FileMetadata{
+			FileName: res.File,
+			Subjects: map[rewrite.Subject]struct{}{},
+		}
+		for _, subject := range res.Subjects {
+			if config.Matches(keep, subject.Name) {
+				continue
+			}
+			metadata.Subjects[rewrite.Subject{Name: subject.Name, Kind: kindToSubjectKind[subject.Kind]}] = struct{}{}
+		}
+		unusedMetadata[res.File] = metadata
+		fileSet[res.File] = struct{}{}
+	}
+
+	return fileSet, unusedMetadata
+}
+
+func handleUnused(fileSet map[string]struct{}, unusedMetadata map[string]*rewrite.FileMetadata, skipFiles []*regexp.Regexp, opts outputOptions) {
+	var reports []fileReport
 	for fileName := range fileSet {
-		if strings.Contains(fileName, "generate") {
-			// We don't want to touch generated files.
+		if config.Matches(skipFiles, fileName) {
+			// Covers //go:generate'd files by default; see the
+			// skip_files default in internal/config.
+			continue
+		}
+		if strings.Contains(fileName, string(filepath.Separator)+"vendor"+string(filepath.Separator)) {
+			// Never rewrite vendored code.
 			continue
 		}
 		log.Printf("Processing file: %s...", fileName)
+		original, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			log.Fatal(err)
+		}
 		fset := token.NewFileSet()
-		node, err := parser.ParseFile(fset, filepath.Join(fileName), nil, parser.ParseComments)
+		node, err := parser.ParseFile(fset, fileName, original, parser.ParseComments)
 		if err != nil {
 			log.Fatal(err)
 		}
-		var usedDecls []ast.Decl
 		cmap := ast.NewCommentMap(fset, node, node.Comments)
-		thisMetadata := unusedMetadata[fileName]
-		for _, decl := range node.Decls {
-			fn, ok := decl.(*ast.FuncDecl)
-			if ok {
-				// If the function is unused, remove it.
-				if _, ok := thisMetadata.subjects[unusedSubject{fn.Name.Name, unusedFunc}]; ok {
-					continue
-				}
-
-				// If this is a method and the receiver type is unused, remove the method.
-				if fn.Recv != nil {
-					var receiverTypeName string
-					switch typ := fn.Recv.List[0].Type.(type) {
-					case *ast.StarExpr:
-						if si, ok := typ.X.(*ast.Ident); ok {
-							receiverTypeName = si.Name
-						}
-					case *ast.Ident:
-						receiverTypeName = typ.Name
-					}
-					if _, ok := thisMetadata.subjects[unusedSubject{receiverTypeName, unusedType}]; ok {
-						continue
-					}
-					if _, ok := thisMetadata.subjects[unusedSubject{fmt.Sprintf("%s.%s", receiverTypeName, fn.Name.Name), unusedMethod}]; ok {
-						continue
-					}
-				}
-			}
-
-			gn, ok := decl.(*ast.GenDecl)
-			if ok {
-				if typeSpec, ok := gn.Specs[0].(*ast.TypeSpec); ok {
-					// If the struct is unused, remove it.
-					if _, ok = thisMetadata.subjects[unusedSubject{typeSpec.Name.Name, unusedType}]; ok {
-						continue
-					}
-				}
-			}
-
-			usedDecls = append(usedDecls, decl)
-			node.Decls = usedDecls
-			// Delete comments associated with the removed functions.
-			node.Comments = cmap.Filter(node).Comments()
-		}
+		removals := rewrite.Rewrite(node, cmap, unusedMetadata[fileName])
 
 		var buf bytes.Buffer
-		err = format.Node(&buf, fset, node)
-		if err != nil {
+		if err := format.Node(&buf, fset, node); err != nil {
 			log.Fatal(err)
 		}
 
-		err = ioutil.WriteFile(fileName, buf.Bytes(), 0)
-		if err != nil {
+		switch {
+		case opts.JSON:
+			var removed []removedItem
+			for _, r := range removals {
+				removed = append(removed, removedItem{string(r.Kind), r.Name, fset.Position(r.Node.Pos()).Line})
+			}
+			reports = append(reports, fileReport{File: fileName, Removed: removed})
+		case opts.DryRun:
+			if diffText := udiff.Unified(fileName, original, buf.Bytes()); diffText != "" {
+				fmt.Print(diffText)
+			}
+		default:
+			if err := ioutil.WriteFile(fileName, buf.Bytes(), 0); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	if opts.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(reports); err != nil {
 			log.Fatal(err)
 		}
 	}
 }
-
-